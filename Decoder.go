@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2014 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import "io"
+
+type DecoderOptions struct {
+    // ContentTypes is the list of content types this Decoder is willing to
+    // accept. In bidirectional mode, the entry chosen from the peer's READY
+    // frame is advertised back in the ACCEPT frame.
+    ContentTypes    [][]byte
+
+    // ContentType is a convenience alias for ContentTypes when only a
+    // single content type needs to be accepted. It is ignored if
+    // ContentTypes is non-empty.
+    ContentType     []byte
+
+    Bidirectional   bool
+
+    // Compression, if non-nil, unwraps every data frame payload with the
+    // given Codec. It is negotiated by suffixing each accepted content
+    // type with "+" + Compression.Name(), e.g. "protobuf:dnstap+zstd".
+    Compression     Codec
+}
+
+// Decoder drives the Frame Streams handshake and read side of a stream on
+// top of a Framer.
+type Decoder struct {
+    framer *Framer
+    opt    DecoderOptions
+}
+
+func NewDecoder(v interface{}, opt *DecoderOptions) (dec *Decoder, err error) {
+    r, ok := v.(io.Reader)
+    if ! ok {
+       return dec, ErrType
+    }
+    if opt == nil {
+        opt = &DecoderOptions{}
+    }
+    if len(opt.ContentTypes) == 0 && opt.ContentType != nil {
+        opt.ContentTypes = [][]byte{opt.ContentType}
+    }
+
+    var w io.Writer
+    if opt.Bidirectional {
+        w, ok = v.(io.Writer)
+        if ! ok {
+           return dec, ErrType
+        }
+    }
+
+    dec = &Decoder{
+        framer: NewFramer(r, w),
+        opt:    *opt,
+    }
+
+    // The content types we accept carry the negotiated compression codec,
+    // if any, as a "+name" suffix.
+    acceptedContentTypes := suffixContentTypes(dec.opt.ContentTypes, dec.opt.Compression)
+
+    if opt.Bidirectional {
+        // Read the ready control frame.
+        fr, err := dec.framer.ReadFrame()
+        if err != nil {
+            return dec, err
+        }
+        rf, ok := fr.(*ReadyFrame)
+        if ! ok {
+            return dec, ErrDecode
+        }
+
+        // Select a content type from the ones the peer advertised.
+        matched := matchContentTypes(acceptedContentTypes, rf.ContentTypes)
+        if len(matched) < 1 {
+            return dec, ErrContentTypeMismatch
+        }
+
+        // Write the accept control frame, advertising the selection.
+        err = dec.framer.WriteFrame(&AcceptFrame{ContentTypes: matched[:1]})
+        if err != nil {
+            return dec, err
+        }
+    }
+
+    // Read the start control frame.
+    fr, err := dec.framer.ReadFrame()
+    if err != nil {
+        return dec, err
+    }
+    sf, ok := fr.(*StartFrame)
+    if ! ok {
+        return dec, ErrDecode
+    }
+
+    // In unidirectional mode, the content type wasn't negotiated above, so
+    // check it against the accepted set here.
+    if ! opt.Bidirectional && len(acceptedContentTypes) > 0 {
+        matched := matchContentTypes(acceptedContentTypes, sf.ContentTypes)
+        if len(matched) != 1 {
+            return dec, ErrContentTypeMismatch
+        }
+    }
+
+    return
+}
+
+// Decode reads the next data frame from the stream, returning its payload.
+// It returns io.EOF once the peer sends a STOP control frame; in
+// bidirectional mode, a FINISH control frame is written back automatically
+// before returning.
+func (dec *Decoder) Decode() (frame []byte, err error) {
+    fr, err := dec.framer.ReadFrame()
+    if err != nil {
+        return nil, err
+    }
+    switch t := fr.(type) {
+    case *DataFrame:
+        return decompressFrame(dec.opt.Compression, t.Data)
+    case *StopFrame:
+        if dec.opt.Bidirectional {
+            if err := dec.framer.WriteFrame(&FinishFrame{}); err != nil {
+                return nil, err
+            }
+        }
+        return nil, io.EOF
+    default:
+        return nil, ErrDecode
+    }
+}
@@ -16,21 +16,49 @@
 
 package framestream
 
-import "bufio"
-import "bytes"
-import "encoding/binary"
+import "context"
 import "io"
+import "time"
 
 type EncoderOptions struct {
+    // ContentTypes is the list of content types advertised in the READY
+    // control frame of a bidirectional handshake, in order of preference.
+    // In unidirectional mode, only the first entry (if any) is sent in the
+    // START control frame.
+    ContentTypes    [][]byte
+
+    // ContentType is a convenience alias for ContentTypes when only a
+    // single content type needs to be advertised. It is ignored if
+    // ContentTypes is non-empty.
     ContentType     []byte
+
     Bidirectional   bool
+
+    // Compression, if non-nil, wraps every data frame payload with the
+    // given Codec. It is negotiated by suffixing each advertised content
+    // type with "+" + Compression.Name(), e.g. "protobuf:dnstap+zstd".
+    Compression     Codec
+
+    // ShutdownTimeout bounds how long Close waits for the peer's FINISH
+    // frame in bidirectional mode. It is honored whether or not the reader
+    // passed to NewEncoder supports SetReadDeadline (e.g. a net.Conn); see
+    // CloseContext. A zero value means wait indefinitely unless the
+    // context passed to CloseContext is done first.
+    ShutdownTimeout time.Duration
 }
 
+// deadlineSetter is implemented by connections, such as net.Conn, that
+// support read deadlines.
+type deadlineSetter interface {
+    SetReadDeadline(t time.Time) error
+}
+
+// Encoder drives the Frame Streams handshake and write side of a stream on
+// top of a Framer.
 type Encoder struct {
-    reader          *bufio.Reader
-    writer          *bufio.Writer
-    opt             EncoderOptions
-    buf             []byte
+    framer *Framer
+    opt    EncoderOptions
+    reader io.Reader
 }
 
 func NewEncoder(v interface{}, opt *EncoderOptions) (enc *Encoder, err error) {
@@ -41,40 +69,65 @@ func NewEncoder(v interface{}, opt *EncoderOptions) (enc *Encoder, err error) {
     if opt == nil {
         opt = &EncoderOptions{}
     }
-    enc = &Encoder{
-        reader:     nil,
-        writer:     bufio.NewWriter(w),
-        opt:        *opt,
+    if len(opt.ContentTypes) == 0 && opt.ContentType != nil {
+        opt.ContentTypes = [][]byte{opt.ContentType}
     }
 
+    var r io.Reader
     if opt.Bidirectional {
-        r, ok := v.(io.Reader)
+        r, ok = v.(io.Reader)
         if ! ok {
            return enc, ErrType
         }
-        enc.reader = bufio.NewReader(r)
+    }
+
+    enc = &Encoder{
+        framer: NewFramer(r, w),
+        opt:    *opt,
+        reader: r,
+    }
+
+    // The content types we advertise carry the negotiated compression
+    // codec, if any, as a "+name" suffix.
+    advertisedContentTypes := suffixContentTypes(enc.opt.ContentTypes, enc.opt.Compression)
 
-        // Write the ready control frame.
-        err = enc.writeControlReady()
+    // The content type(s) to advertise in the START control frame. In
+    // unidirectional mode this is simply the first advertised content
+    // type; in bidirectional mode it is negotiated below.
+    startContentTypes := advertisedContentTypes
+    if len(startContentTypes) > 1 {
+        startContentTypes = startContentTypes[:1]
+    }
+
+    if opt.Bidirectional {
+        // Write the ready control frame, advertising all acceptable
+        // content types.
+        err = enc.framer.WriteFrame(&ReadyFrame{ContentTypes: advertisedContentTypes})
         if err != nil {
             return enc, err
         }
 
         // Read the accept control frame.
-        cf, err := enc.readControlFrameType(CONTROL_ACCEPT)
+        fr, err := enc.framer.ReadFrame()
         if err != nil {
             return enc, err
         }
+        af, ok := fr.(*AcceptFrame)
+        if ! ok {
+            return enc, ErrDecode
+        }
 
-        // Check content type.
-        matched := matchContentTypes(cf.ContentTypes, [][]byte{enc.opt.ContentType})
+        // Intersect the content types we advertised with the ones the
+        // peer accepted, and carry the selection into the START frame.
+        matched := matchContentTypes(advertisedContentTypes, af.ContentTypes)
         if len(matched) != 1 {
             return enc, ErrContentTypeMismatch
         }
+        startContentTypes = matched[:1]
     }
 
     // Write the start control frame.
-    err = enc.writeControlStart()
+    err = enc.framer.WriteFrame(&StartFrame{ContentTypes: startContentTypes})
     if err != nil {
         return
     }
@@ -82,143 +135,81 @@ func NewEncoder(v interface{}, opt *EncoderOptions) (enc *Encoder, err error) {
     return
 }
 
+// Close writes a STOP control frame. In bidirectional mode it then waits
+// for the peer's FINISH control frame, bounded by EncoderOptions.ShutdownTimeout.
 func (enc *Encoder) Close() error {
-    return enc.writeControlStop()
+    return enc.CloseContext(context.Background())
 }
 
-func (enc *Encoder) readControlFrameType(controlType int) (cf *ControlFrame, err error) {
-    return readControlFrameType(enc.reader, uint32(controlType))
-}
-
-
-func (enc *Encoder) writeControlFrameAndContentType(controlType int) (err error) {
-    totalLen := 0
-
-    // Calculate the total amount of space needed for the control frame.
-
-    // Escape: 32-bit BE integer. Zero.
-    totalLen += 4
-
-    // Frame length: 32-bit BE integer.
-    totalLen += 4
-
-    // Control type: 32-bit BE integer.
-    totalLen += 4
-
-    if enc.opt.ContentType != nil {
-        // CONTROL_FIELD_CONTENT_TYPE: 32-bit BE integer.
-        totalLen += 4
-
-        // Length of content type string: 32-bit BE integer.
-        totalLen += 4
-
-        // The content type string itself.
-        totalLen += len(enc.opt.ContentType)
-    }
-
-    // Allocate the storage for the control frame.
-    buf := new(bytes.Buffer)
-
-    // Now actually serialize the control frame.
-
-    // Escape: 32-bit BE integer. Zero.
-    err = binary.Write(buf, binary.BigEndian, uint32(0))
-    if err != nil {
-        return
+// CloseContext writes a STOP control frame and, in bidirectional mode,
+// waits for the peer's FINISH control frame until ctx is done or
+// EncoderOptions.ShutdownTimeout elapses, whichever comes first. This is
+// honored even if the reader passed to NewEncoder doesn't support
+// SetReadDeadline; in that case the blocked read is abandoned in the
+// background (it is not, and cannot be, canceled) rather than making the
+// caller wait for it.
+func (enc *Encoder) CloseContext(ctx context.Context) error {
+    if err := enc.framer.WriteFrame(&StopFrame{}); err != nil {
+        return err
+    }
+    if ! enc.opt.Bidirectional {
+        return nil
+    }
+
+    if enc.opt.ShutdownTimeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, enc.opt.ShutdownTimeout)
+        defer cancel()
+    }
+
+    // If the reader supports read deadlines, set one so the read below
+    // actually unblocks when ctx is done instead of merely being
+    // abandoned.
+    if ds, ok := enc.reader.(deadlineSetter); ok {
+        if deadline, hasDeadline := ctx.Deadline(); hasDeadline {
+            if err := ds.SetReadDeadline(deadline); err != nil {
+                return err
+            }
+            defer ds.SetReadDeadline(time.Time{})
+        }
     }
 
-    // Frame length: 32-bit BE integer.
-    //
-    // This does not include the length of the escape frame or the length of
-    // the frame length field itself, so subtract 2*4 bytes from the total
-    // length.
-    err = binary.Write(buf, binary.BigEndian, uint32(totalLen - 2*4))
-    if err != nil {
-        return
+    type result struct {
+        fr  Frame
+        err error
     }
+    ch := make(chan result, 1)
+    go func() {
+        fr, err := enc.framer.ReadFrame()
+        ch <- result{fr, err}
+    }()
 
-    // Control type: 32-bit BE integer.
-    err = binary.Write(buf, binary.BigEndian, uint32(controlType))
-    if err != nil {
-        return
-    }
-
-    if enc.opt.ContentType != nil {
-        // FSTRM_CONTROL_FIELD_CONTENT_TYPE: 32-bit BE integer.
-        err = binary.Write(buf, binary.BigEndian, uint32(CONTROL_FIELD_CONTENT_TYPE))
-        if err != nil {
-            return
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case res := <-ch:
+        if res.err != nil {
+            return res.err
         }
-
-        // Length of content type string: 32-bit BE integer.
-        err = binary.Write(buf, binary.BigEndian, uint32(len(enc.opt.ContentType)))
-        if err != nil {
-            return
+        if _, ok := res.fr.(*FinishFrame); ! ok {
+            return ErrDecode
         }
-
-        // The content type string itself.
-        _, err = buf.Write(enc.opt.ContentType)
-        if err != nil {
-            return
-        }
-    }
-
-    // Write the control frame.
-    _, err = buf.WriteTo(enc.writer)
-    if err != nil {
-        return
+        return nil
     }
-
-    return enc.Flush()
-}
-
-func (enc *Encoder) writeControlReady() (err error) {
-    return enc.writeControlFrameAndContentType(CONTROL_READY)
 }
 
-func (enc *Encoder) writeControlStart() (err error) {
-    return enc.writeControlFrameAndContentType(CONTROL_START)
-}
-
-func (enc *Encoder) writeControlStop() (err error) {
-    totalLen := 3*4
-    buf := new(bytes.Buffer)
-
-    // Escape: 32-bit BE integer. Zero.
-    err = binary.Write(buf, binary.BigEndian, uint32(0))
-    if err != nil {
-        return
-    }
-
-    // Frame length: 32-bit BE integer.
-    err = binary.Write(buf, binary.BigEndian, uint32(totalLen - 2*4))
-    if err != nil {
-        return
-    }
-
-    // Control type: 32-bit BE integer.
-    err = binary.Write(buf, binary.BigEndian, uint32(CONTROL_STOP))
-    if err != nil {
-        return
-    }
-
-    // Write the control frame.
-    _, err = buf.WriteTo(enc.writer)
+func (enc *Encoder) Write(frame []byte) (n int, err error) {
+    data, err := compressFrame(enc.opt.Compression, frame)
     if err != nil {
-        return
+        return 0, err
     }
-
-    return enc.Flush()
-}
-
-func (enc *Encoder) Write(frame []byte) (n int, err error) {
-    err = binary.Write(enc.writer, binary.BigEndian, uint32(len(frame)))
+    err = enc.framer.WriteFrame(&DataFrame{Data: data})
     if err != nil {
-        return
+        return 0, err
     }
-    return enc.writer.Write(frame)
+    return len(frame), nil
 }
 
 func (enc *Encoder) Flush() error {
-    return enc.writer.Flush()
+    return enc.framer.Flush()
 }
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2014 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import "context"
+import "io"
+import "io/ioutil"
+import "testing"
+import "time"
+
+func benchmarkEncoderWrite(b *testing.B, frame []byte) {
+    enc, err := NewEncoder(ioutil.Discard, &EncoderOptions{})
+    if err != nil {
+        b.Fatal(err)
+    }
+    b.SetBytes(int64(len(frame)))
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        if _, err := enc.Write(frame); err != nil {
+            b.Fatal(err)
+        }
+    }
+}
+
+func BenchmarkEncoderWriteSmall(b *testing.B) {
+    benchmarkEncoderWrite(b, make([]byte, 64))
+}
+
+func BenchmarkEncoderWriteLarge(b *testing.B) {
+    benchmarkEncoderWrite(b, make([]byte, 16384))
+}
+
+// TestCloseContextHonorsDeadlineWithoutDeadlineSetter verifies that
+// CloseContext returns once ctx is done even when the underlying reader
+// (here, an io.Pipe that nothing ever writes to) doesn't implement
+// SetReadDeadline.
+func TestCloseContextHonorsDeadlineWithoutDeadlineSetter(t *testing.T) {
+    pr, pw := io.Pipe()
+    defer pw.Close()
+
+    enc := &Encoder{
+        framer: NewFramer(pr, ioutil.Discard),
+        opt:    EncoderOptions{Bidirectional: true},
+        reader: pr,
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+
+    start := time.Now()
+    err := enc.CloseContext(ctx)
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Fatalf("CloseContext took %v, want well under 1s", elapsed)
+    }
+    if err != context.DeadlineExceeded {
+        t.Fatalf("CloseContext returned %v, want %v", err, context.DeadlineExceeded)
+    }
+}
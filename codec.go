@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2014 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+// Codec compresses and decompresses individual data frame payloads. It is
+// selected via EncoderOptions.Compression / DecoderOptions.Compression and
+// negotiated through the existing content type handshake: the advertised
+// content type is suffixed with "+" + Name(), e.g. "protobuf:dnstap+zstd".
+type Codec interface {
+    Encode(dst, src []byte) ([]byte, error)
+    Decode(dst, src []byte) ([]byte, error)
+    Name() string
+}
+
+// suffixContentType appends "+" + codec.Name() to ct if codec is non-nil.
+func suffixContentType(ct []byte, codec Codec) []byte {
+    if codec == nil {
+        return ct
+    }
+    return append(append(append([]byte{}, ct...), '+'), []byte(codec.Name())...)
+}
+
+// suffixContentTypes applies suffixContentType to every entry in cts.
+func suffixContentTypes(cts [][]byte, codec Codec) [][]byte {
+    if codec == nil {
+        return cts
+    }
+    out := make([][]byte, len(cts))
+    for i, ct := range cts {
+        out[i] = suffixContentType(ct, codec)
+    }
+    return out
+}
+
+// noneCodec is the identity Codec. A nil Compression bypasses codecs
+// entirely; noneCodec is only used when set explicitly, and then advertises
+// the "+none" suffix.
+type noneCodec struct{}
+
+func (noneCodec) Encode(dst, src []byte) ([]byte, error) {
+    return append(dst, src...), nil
+}
+
+func (noneCodec) Decode(dst, src []byte) ([]byte, error) {
+    return append(dst, src...), nil
+}
+
+func (noneCodec) Name() string {
+    return "none"
+}
+
+// NoneCodec is a Codec that performs no compression.
+var NoneCodec Codec = noneCodec{}
+
+func compressFrame(codec Codec, frame []byte) ([]byte, error) {
+    if codec == nil {
+        return frame, nil
+    }
+    return codec.Encode(nil, frame)
+}
+
+func decompressFrame(codec Codec, frame []byte) ([]byte, error) {
+    if codec == nil {
+        return frame, nil
+    }
+    return codec.Decode(nil, frame)
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2014 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import "bytes"
+import "compress/gzip"
+import "compress/zlib"
+import "io/ioutil"
+
+// gzipCodec compresses data frames with gzip.
+type gzipCodec struct{}
+
+// GzipCodec is a Codec that compresses data frames with gzip.
+var GzipCodec Codec = gzipCodec{}
+
+func (gzipCodec) Encode(dst, src []byte) ([]byte, error) {
+    buf := bytes.NewBuffer(dst)
+    w := gzip.NewWriter(buf)
+    if _, err := w.Write(src); err != nil {
+        return nil, err
+    }
+    if err := w.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(dst, src []byte) ([]byte, error) {
+    r, err := gzip.NewReader(bytes.NewReader(src))
+    if err != nil {
+        return nil, err
+    }
+    defer r.Close()
+    decoded, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    return append(dst, decoded...), nil
+}
+
+func (gzipCodec) Name() string {
+    return "gzip"
+}
+
+// zlibCodec compresses data frames with zlib.
+type zlibCodec struct{}
+
+// ZlibCodec is a Codec that compresses data frames with zlib.
+var ZlibCodec Codec = zlibCodec{}
+
+func (zlibCodec) Encode(dst, src []byte) ([]byte, error) {
+    buf := bytes.NewBuffer(dst)
+    w := zlib.NewWriter(buf)
+    if _, err := w.Write(src); err != nil {
+        return nil, err
+    }
+    if err := w.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func (zlibCodec) Decode(dst, src []byte) ([]byte, error) {
+    r, err := zlib.NewReader(bytes.NewReader(src))
+    if err != nil {
+        return nil, err
+    }
+    defer r.Close()
+    decoded, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    return append(dst, decoded...), nil
+}
+
+func (zlibCodec) Name() string {
+    return "zlib"
+}
@@ -0,0 +1,58 @@
+//go:build zstd
+// +build zstd
+
+/*
+ * Copyright (c) 2014 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// The zstd Codec pulls in github.com/klauspost/compress/zstd, which is not
+// part of the standard library, so it's opt-in via the "zstd" build tag:
+//
+//     go build -tags zstd ./...
+
+package framestream
+
+import "github.com/klauspost/compress/zstd"
+
+// zstdCodec compresses data frames with zstd.
+type zstdCodec struct {
+    encoder *zstd.Encoder
+    decoder *zstd.Decoder
+}
+
+// NewZstdCodec creates a Codec that compresses data frames with zstd.
+func NewZstdCodec() (Codec, error) {
+    enc, err := zstd.NewWriter(nil)
+    if err != nil {
+        return nil, err
+    }
+    dec, err := zstd.NewReader(nil)
+    if err != nil {
+        return nil, err
+    }
+    return &zstdCodec{encoder: enc, decoder: dec}, nil
+}
+
+func (c *zstdCodec) Encode(dst, src []byte) ([]byte, error) {
+    return c.encoder.EncodeAll(src, dst), nil
+}
+
+func (c *zstdCodec) Decode(dst, src []byte) ([]byte, error) {
+    return c.decoder.DecodeAll(src, dst)
+}
+
+func (c *zstdCodec) Name() string {
+    return "zstd"
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2014 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import "bytes"
+import "reflect"
+import "testing"
+
+func TestReadyFrameRoundTripMultipleContentTypes(t *testing.T) {
+    want := [][]byte{
+        []byte("protobuf:dnstap.Dnstap"),
+        []byte("protobuf:dnstap.Dnstap+zstd"),
+        []byte("protobuf:dnstap.Dnstap+gzip"),
+    }
+
+    var buf bytes.Buffer
+    wf := NewFramer(nil, &buf)
+    if err := wf.WriteFrame(&ReadyFrame{ContentTypes: want}); err != nil {
+        t.Fatalf("WriteFrame: %v", err)
+    }
+
+    rf := NewFramer(&buf, nil)
+    fr, err := rf.ReadFrame()
+    if err != nil {
+        t.Fatalf("ReadFrame: %v", err)
+    }
+
+    ready, ok := fr.(*ReadyFrame)
+    if ! ok {
+        t.Fatalf("ReadFrame returned %T, want *ReadyFrame", fr)
+    }
+    if ! reflect.DeepEqual(ready.ContentTypes, want) {
+        t.Fatalf("got ContentTypes %q, want %q", ready.ContentTypes, want)
+    }
+}
+
+func TestReadyFrameRoundTripSingleContentType(t *testing.T) {
+    want := [][]byte{[]byte("protobuf:dnstap.Dnstap")}
+
+    var buf bytes.Buffer
+    wf := NewFramer(nil, &buf)
+    if err := wf.WriteFrame(&ReadyFrame{ContentTypes: want}); err != nil {
+        t.Fatalf("WriteFrame: %v", err)
+    }
+
+    rf := NewFramer(&buf, nil)
+    fr, err := rf.ReadFrame()
+    if err != nil {
+        t.Fatalf("ReadFrame: %v", err)
+    }
+
+    ready, ok := fr.(*ReadyFrame)
+    if ! ok {
+        t.Fatalf("ReadFrame returned %T, want *ReadyFrame", fr)
+    }
+    if ! reflect.DeepEqual(ready.ContentTypes, want) {
+        t.Fatalf("got ContentTypes %q, want %q", ready.ContentTypes, want)
+    }
+}
+
+func TestAcceptStartFrameRoundTrip(t *testing.T) {
+    contentTypes := [][]byte{[]byte("a"), []byte("bb"), []byte("ccc")}
+
+    var buf bytes.Buffer
+    wf := NewFramer(nil, &buf)
+    if err := wf.WriteFrame(&AcceptFrame{ContentTypes: contentTypes}); err != nil {
+        t.Fatalf("WriteFrame(AcceptFrame): %v", err)
+    }
+    if err := wf.WriteFrame(&StartFrame{ContentTypes: contentTypes[:1]}); err != nil {
+        t.Fatalf("WriteFrame(StartFrame): %v", err)
+    }
+
+    rf := NewFramer(&buf, nil)
+
+    fr, err := rf.ReadFrame()
+    if err != nil {
+        t.Fatalf("ReadFrame(AcceptFrame): %v", err)
+    }
+    accept, ok := fr.(*AcceptFrame)
+    if ! ok {
+        t.Fatalf("ReadFrame returned %T, want *AcceptFrame", fr)
+    }
+    if ! reflect.DeepEqual(accept.ContentTypes, contentTypes) {
+        t.Fatalf("got ContentTypes %q, want %q", accept.ContentTypes, contentTypes)
+    }
+
+    fr, err = rf.ReadFrame()
+    if err != nil {
+        t.Fatalf("ReadFrame(StartFrame): %v", err)
+    }
+    start, ok := fr.(*StartFrame)
+    if ! ok {
+        t.Fatalf("ReadFrame returned %T, want *StartFrame", fr)
+    }
+    if ! reflect.DeepEqual(start.ContentTypes, contentTypes[:1]) {
+        t.Fatalf("got ContentTypes %q, want %q", start.ContentTypes, contentTypes[:1])
+    }
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright (c) 2014 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import "bufio"
+import "encoding/binary"
+import "io"
+
+func readBE32(reader *bufio.Reader) (val uint32, err error) {
+    err = binary.Read(reader, binary.BigEndian, &val)
+    if err != nil {
+        return 0, err
+    }
+    return
+}
+
+// readControlHeader reads the length-prefixed body of a control frame
+// (everything after the escape sequence) and parses out the control type
+// and any CONTROL_FIELD_CONTENT_TYPE fields it carries.
+func (f *Framer) readControlHeader() (h Header, err error) {
+    controlFrameLen, err := readBE32(f.reader)
+    if err != nil {
+        return
+    }
+
+    // Enforce limits on control frame size.
+    if controlFrameLen < 4 || controlFrameLen > MAX_CONTROL_FRAME_SIZE {
+        return h, ErrDecode
+    }
+
+    // Read the control frame.
+    controlFrameData := make([]byte, controlFrameLen)
+    n, err := io.ReadFull(f.reader, controlFrameData)
+    if err != nil || uint32(n) != controlFrameLen {
+        return h, err
+    }
+
+    // Read the control frame type.
+    h.ControlType = binary.BigEndian.Uint32(controlFrameData[0:4])
+
+    // Read the control fields. pos is the offset of the next field's
+    // field-type word, which starts just past the control type.
+    var pos uint32 = 4
+    for pos < controlFrameLen {
+        if pos+4 > controlFrameLen {
+            return h, ErrDecode
+        }
+        controlFieldType := binary.BigEndian.Uint32(controlFrameData[pos : pos+4])
+        switch controlFieldType {
+        case CONTROL_FIELD_CONTENT_TYPE:
+            if pos+8 > controlFrameLen {
+                return h, ErrDecode
+            }
+            lenContentType := binary.BigEndian.Uint32(controlFrameData[pos+4 : pos+8])
+            if lenContentType > MAX_CONTROL_FRAME_SIZE {
+                return h, ErrDecode
+            }
+            if pos+8+lenContentType > controlFrameLen {
+                return h, ErrDecode
+            }
+            contentType := make([]byte, lenContentType)
+            copy(contentType, controlFrameData[pos+8:pos+8+lenContentType])
+            h.ContentTypes = append(h.ContentTypes, contentType)
+            pos += 8 + lenContentType
+        default:
+            return h, ErrDecode
+        }
+    }
+
+    return
+}
+
+// newControlFrame returns a zero-valued Frame of the concrete type
+// corresponding to controlType, or ErrDecode if the type is unrecognized.
+func newControlFrame(controlType uint32) (fr Frame, err error) {
+    switch controlType {
+    case CONTROL_READY:
+        return &ReadyFrame{}, nil
+    case CONTROL_ACCEPT:
+        return &AcceptFrame{}, nil
+    case CONTROL_START:
+        return &StartFrame{}, nil
+    case CONTROL_STOP:
+        return &StopFrame{}, nil
+    case CONTROL_FINISH:
+        return &FinishFrame{}, nil
+    default:
+        return nil, ErrDecode
+    }
+}
+
+// ReadFrame reads the next frame from the underlying reader. The
+// concrete type returned is one of *DataFrame, *ReadyFrame, *AcceptFrame,
+// *StartFrame, *StopFrame, or *FinishFrame.
+func (f *Framer) ReadFrame() (fr Frame, err error) {
+    length, err := readBE32(f.reader)
+    if err != nil {
+        return nil, err
+    }
+
+    if length != 0 {
+        df := &DataFrame{}
+        err = df.read(Header{Length: length}, f)
+        if err != nil {
+            return nil, err
+        }
+        return df, nil
+    }
+
+    // A zero length is the escape sequence introducing a control frame.
+    h, err := f.readControlHeader()
+    if err != nil {
+        return nil, err
+    }
+
+    fr, err = newControlFrame(h.ControlType)
+    if err != nil {
+        return nil, err
+    }
+    err = fr.read(h, f)
+    if err != nil {
+        return nil, err
+    }
+
+    return fr, nil
+}
+
+func (df *DataFrame) read(h Header, f *Framer) (err error) {
+    df.Data = make([]byte, h.Length)
+    n, err := io.ReadFull(f.reader, df.Data)
+    if err != nil || uint32(n) != h.Length {
+        return err
+    }
+    return nil
+}
+
+func (rf *ReadyFrame) read(h Header, f *Framer) (err error) {
+    if h.ControlType != CONTROL_READY {
+        return ErrDecode
+    }
+    rf.ContentTypes = h.ContentTypes
+    return nil
+}
+
+func (af *AcceptFrame) read(h Header, f *Framer) (err error) {
+    if h.ControlType != CONTROL_ACCEPT {
+        return ErrDecode
+    }
+    af.ContentTypes = h.ContentTypes
+    return nil
+}
+
+func (sf *StartFrame) read(h Header, f *Framer) (err error) {
+    if h.ControlType != CONTROL_START {
+        return ErrDecode
+    }
+    if len(h.ContentTypes) > 1 {
+        return ErrDecode
+    }
+    sf.ContentTypes = h.ContentTypes
+    return nil
+}
+
+func (sf *StopFrame) read(h Header, f *Framer) (err error) {
+    if h.ControlType != CONTROL_STOP {
+        return ErrDecode
+    }
+    if len(h.ContentTypes) > 0 {
+        return ErrDecode
+    }
+    return nil
+}
+
+func (ff *FinishFrame) read(h Header, f *Framer) (err error) {
+    if h.ControlType != CONTROL_FINISH {
+        return ErrDecode
+    }
+    if len(h.ContentTypes) > 0 {
+        return ErrDecode
+    }
+    return nil
+}
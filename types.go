@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2014 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package framestream implements the Frame Streams data transport protocol,
+// a lightweight, binary-clean protocol for streaming discrete data frames.
+package framestream
+
+import "bufio"
+import "bytes"
+import "errors"
+import "io"
+
+const (
+    CONTROL_ACCEPT = 0x01
+    CONTROL_START  = 0x02
+    CONTROL_STOP   = 0x03
+    CONTROL_READY  = 0x04
+    CONTROL_FINISH = 0x05
+
+    CONTROL_FIELD_CONTENT_TYPE = 0x01
+
+    MAX_CONTROL_FRAME_SIZE = 512
+)
+
+var (
+    ErrDecode              = errors.New("framestream: data decoding error")
+    ErrType                = errors.New("framestream: data writer/reader doesn't match")
+    ErrContentTypeMismatch = errors.New("framestream: content type mismatch")
+)
+
+// Header describes the generic length prefix that precedes every frame on
+// the wire. A Length of zero is the escape sequence that introduces a
+// control frame, in which case ControlType and ContentTypes describe the
+// control frame that follows; any other value is the length in bytes of a
+// data frame.
+type Header struct {
+    Length       uint32
+    ControlType  uint32
+    ContentTypes [][]byte
+}
+
+// Frame is implemented by every frame type that can appear on a Frame
+// Streams connection: DataFrame, and the control frames ReadyFrame,
+// AcceptFrame, StartFrame, StopFrame, and FinishFrame.
+type Frame interface {
+    read(h Header, f *Framer) error
+    write(f *Framer) error
+}
+
+// DataFrame carries an opaque, application-defined payload.
+type DataFrame struct {
+    Data []byte
+}
+
+// ReadyFrame is sent by a bidirectional Encoder to advertise the content
+// types it is able to send.
+type ReadyFrame struct {
+    ContentTypes [][]byte
+}
+
+// AcceptFrame is sent in reply to a ReadyFrame, selecting a content type
+// from the set offered.
+type AcceptFrame struct {
+    ContentTypes [][]byte
+}
+
+// StartFrame begins a sequence of data frames, optionally declaring the
+// single content type that the data frames will be encoded with.
+type StartFrame struct {
+    ContentTypes [][]byte
+}
+
+// StopFrame ends a sequence of data frames.
+type StopFrame struct {
+}
+
+// FinishFrame acknowledges a StopFrame in bidirectional mode.
+type FinishFrame struct {
+}
+
+// Framer reads and writes frames on an underlying io.Reader and/or
+// io.Writer. Encoder and Decoder are policy wrappers that drive the
+// handshake state machine on top of a Framer.
+type Framer struct {
+    reader *bufio.Reader
+    writer *bufio.Writer
+
+    // lenbuf and ctrlbuf are scratch space reused across calls to
+    // WriteFrame to avoid allocating on every data frame and control
+    // frame written.
+    lenbuf  [4]byte
+    ctrlbuf bytes.Buffer
+}
+
+// NewFramer creates a Framer. Either r or w may be nil if the Framer will
+// only be used for reading or only for writing, respectively.
+func NewFramer(r io.Reader, w io.Writer) *Framer {
+    f := &Framer{}
+    if r != nil {
+        f.reader = bufio.NewReader(r)
+    }
+    if w != nil {
+        f.writer = bufio.NewWriter(w)
+    }
+    return f
+}
+
+func matchContentTypes(a [][]byte, b [][]byte) (c [][]byte) {
+    matched := make([][]byte, 0, 0)
+    for _, contentTypeA := range a {
+        for _, contentTypeB := range b {
+            if bytes.Compare(contentTypeA, contentTypeB) == 0 {
+                matched = append(matched, contentTypeA)
+            }
+        }
+    }
+    return matched
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2014 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import "encoding/binary"
+
+// WriteFrame writes fr to the underlying writer.
+func (f *Framer) WriteFrame(fr Frame) error {
+    return fr.write(f)
+}
+
+// Flush flushes any buffered data to the underlying writer.
+func (f *Framer) Flush() error {
+    return f.writer.Flush()
+}
+
+// writeControlFrame serializes and writes a control frame consisting of a
+// control type and zero or more CONTROL_FIELD_CONTENT_TYPE fields. It
+// reuses f.ctrlbuf as scratch space rather than allocating a fresh buffer
+// on every call.
+func writeControlFrame(f *Framer, controlType uint32, contentTypes [][]byte) (err error) {
+    totalLen := 0
+
+    // Calculate the total amount of space needed for the control frame.
+
+    // Escape: 32-bit BE integer. Zero.
+    totalLen += 4
+
+    // Frame length: 32-bit BE integer.
+    totalLen += 4
+
+    // Control type: 32-bit BE integer.
+    totalLen += 4
+
+    for _, contentType := range contentTypes {
+        // CONTROL_FIELD_CONTENT_TYPE: 32-bit BE integer.
+        totalLen += 4
+
+        // Length of content type string: 32-bit BE integer.
+        totalLen += 4
+
+        // The content type string itself.
+        totalLen += len(contentType)
+    }
+
+    // Reuse the scratch buffer for the control frame.
+    buf := &f.ctrlbuf
+    buf.Reset()
+
+    // Now actually serialize the control frame.
+
+    // Escape: 32-bit BE integer. Zero.
+    err = binary.Write(buf, binary.BigEndian, uint32(0))
+    if err != nil {
+        return
+    }
+
+    // Frame length: 32-bit BE integer.
+    //
+    // This does not include the length of the escape frame or the length of
+    // the frame length field itself, so subtract 2*4 bytes from the total
+    // length.
+    err = binary.Write(buf, binary.BigEndian, uint32(totalLen-2*4))
+    if err != nil {
+        return
+    }
+
+    // Control type: 32-bit BE integer.
+    err = binary.Write(buf, binary.BigEndian, controlType)
+    if err != nil {
+        return
+    }
+
+    for _, contentType := range contentTypes {
+        // CONTROL_FIELD_CONTENT_TYPE: 32-bit BE integer.
+        err = binary.Write(buf, binary.BigEndian, uint32(CONTROL_FIELD_CONTENT_TYPE))
+        if err != nil {
+            return
+        }
+
+        // Length of content type string: 32-bit BE integer.
+        err = binary.Write(buf, binary.BigEndian, uint32(len(contentType)))
+        if err != nil {
+            return
+        }
+
+        // The content type string itself.
+        _, err = buf.Write(contentType)
+        if err != nil {
+            return
+        }
+    }
+
+    // Write the control frame.
+    _, err = buf.WriteTo(f.writer)
+    if err != nil {
+        return
+    }
+
+    return f.writer.Flush()
+}
+
+func (df *DataFrame) write(f *Framer) (err error) {
+    binary.BigEndian.PutUint32(f.lenbuf[:], uint32(len(df.Data)))
+    _, err = f.writer.Write(f.lenbuf[:])
+    if err != nil {
+        return
+    }
+    _, err = f.writer.Write(df.Data)
+    return
+}
+
+func (rf *ReadyFrame) write(f *Framer) error {
+    return writeControlFrame(f, CONTROL_READY, rf.ContentTypes)
+}
+
+func (af *AcceptFrame) write(f *Framer) error {
+    return writeControlFrame(f, CONTROL_ACCEPT, af.ContentTypes)
+}
+
+func (sf *StartFrame) write(f *Framer) error {
+    return writeControlFrame(f, CONTROL_START, sf.ContentTypes)
+}
+
+func (sf *StopFrame) write(f *Framer) error {
+    return writeControlFrame(f, CONTROL_STOP, nil)
+}
+
+func (ff *FinishFrame) write(f *Framer) error {
+    return writeControlFrame(f, CONTROL_FINISH, nil)
+}